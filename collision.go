@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// collisionPolicy controls what happens when two different source files
+// resolve to the same name-based destination path (this only applies to
+// the date tree; the content tree is addressed by hash and can't collide).
+type collisionPolicy string
+
+const (
+	collisionSkip      collisionPolicy = "skip"
+	collisionRename    collisionPolicy = "rename"
+	collisionOverwrite collisionPolicy = "overwrite"
+	collisionError     collisionPolicy = "error"
+)
+
+func parseCollisionPolicy(s string) (collisionPolicy, error) {
+	switch collisionPolicy(s) {
+	case collisionSkip, collisionRename, collisionOverwrite, collisionError:
+		return collisionPolicy(s), nil
+	default:
+		return "", fmt.Errorf("unknown -on-collision %q (want %q, %q, %q, or %q)",
+			s, collisionSkip, collisionRename, collisionOverwrite, collisionError)
+	}
+}
+
+// maxCollisionRetries bounds the "-1", "-2", ... suffix search performed
+// under collisionRename, so a pathological run can't loop forever.
+const maxCollisionRetries = 10000
+
+// collisionResult is what resolveCollision decided to do about a naming
+// collision: write reports whether the caller should still copy/link bytes
+// to dest, duplicate reports whether dest already holds the same content
+// (relevant for stats when write is false).
+type collisionResult struct {
+	dest      string
+	write     bool
+	duplicate bool
+}
+
+// resolveCollision decides how to handle a file whose destination path is
+// already taken by different content. dest is the original, colliding path;
+// hash is the incoming file's content hash. ledger is consulted alongside
+// the real filesystem so that a collision between two files introduced in
+// the same -dry-run is caught even though neither was actually written.
+func resolveCollision(ledger destLedger, policy collisionPolicy, dest, hash string) (collisionResult, error) {
+	switch policy {
+	case collisionSkip:
+		logCollision(policy, dest, dest)
+		return collisionResult{}, nil
+
+	case collisionOverwrite:
+		logCollision(policy, dest, dest)
+		return collisionResult{dest: dest, write: true}, nil
+
+	case collisionError:
+		return collisionResult{}, fmt.Errorf("collision at %s: destination exists with different content", dest)
+
+	case collisionRename:
+		ext := filepath.Ext(dest)
+		base := strings.TrimSuffix(dest, ext)
+		for i := 1; i <= maxCollisionRetries; i++ {
+			candidate := fmt.Sprintf("%s-%d%s", base, i, ext)
+			existingHash, exists, err := ledger.stateOf(candidate)
+			if err != nil {
+				return collisionResult{}, err
+			}
+			if !exists {
+				logCollision(policy, dest, candidate)
+				return collisionResult{dest: candidate, write: true}, nil
+			}
+			if existingHash == hash {
+				logCollision(policy, dest, candidate)
+				return collisionResult{dest: candidate, duplicate: true}, nil
+			}
+		}
+		return collisionResult{}, fmt.Errorf("collision at %s: exhausted %d renames", dest, maxCollisionRetries)
+
+	default:
+		return collisionResult{}, fmt.Errorf("unknown collision policy %q", policy)
+	}
+}
+
+func logCollision(policy collisionPolicy, dest, resolved string) {
+	fmt.Fprintf(os.Stderr, "collision (%s): %s -> %s\n", policy, dest, resolved)
+}
+
+// pathExists reports whether path has a directory entry, without following
+// a trailing symlink.
+func pathExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseCollisionPolicy(t *testing.T) {
+	for _, ok := range []collisionPolicy{collisionSkip, collisionRename, collisionOverwrite, collisionError} {
+		if got, err := parseCollisionPolicy(string(ok)); err != nil || got != ok {
+			t.Errorf("parseCollisionPolicy(%q) = %q, %v; want %q, nil", ok, got, err, ok)
+		}
+	}
+	if _, err := parseCollisionPolicy("bogus"); err == nil {
+		t.Error("parseCollisionPolicy(\"bogus\") returned no error")
+	}
+}
+
+func TestResolveCollisionSkip(t *testing.T) {
+	res, err := resolveCollision(make(destLedger), collisionSkip, "/dest/IMG1.jpg", "hash-b")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.write || res.duplicate {
+		t.Errorf("skip: got %+v, want write=false duplicate=false", res)
+	}
+}
+
+func TestResolveCollisionOverwrite(t *testing.T) {
+	dest := "/dest/IMG1.jpg"
+	res, err := resolveCollision(make(destLedger), collisionOverwrite, dest, "hash-b")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !res.write || res.dest != dest {
+		t.Errorf("overwrite: got %+v, want write=true dest=%q", res, dest)
+	}
+}
+
+func TestResolveCollisionError(t *testing.T) {
+	if _, err := resolveCollision(make(destLedger), collisionError, "/dest/IMG1.jpg", "hash-b"); err == nil {
+		t.Error("error policy: expected an error, got nil")
+	}
+}
+
+func TestResolveCollisionRenameFindsFreeSlot(t *testing.T) {
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "IMG1.jpg")
+
+	res, err := resolveCollision(make(destLedger), collisionRename, dest, "hash-b")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := filepath.Join(dir, "IMG1-1.jpg")
+	if !res.write || res.dest != want {
+		t.Errorf("rename: got %+v, want write=true dest=%q", res, want)
+	}
+}
+
+func TestResolveCollisionRenameSkipsTakenSlots(t *testing.T) {
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "IMG1.jpg")
+
+	// -1 is taken on disk with different content, -2 is taken in the ledger
+	// with different content; the rename should skip both and land on -3.
+	if err := os.WriteFile(filepath.Join(dir, "IMG1-1.jpg"), []byte("other"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ledger := make(destLedger)
+	ledger.record(filepath.Join(dir, "IMG1-2.jpg"), "some-other-hash")
+
+	res, err := resolveCollision(ledger, collisionRename, dest, "hash-b")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := filepath.Join(dir, "IMG1-3.jpg")
+	if !res.write || res.dest != want {
+		t.Errorf("rename: got %+v, want write=true dest=%q", res, want)
+	}
+}
+
+func TestResolveCollisionRenameFindsExistingDuplicate(t *testing.T) {
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "IMG1.jpg")
+
+	ledger := make(destLedger)
+	ledger.record(filepath.Join(dir, "IMG1-1.jpg"), "hash-b")
+
+	res, err := resolveCollision(ledger, collisionRename, dest, "hash-b")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := filepath.Join(dir, "IMG1-1.jpg")
+	if res.write || !res.duplicate || res.dest != want {
+		t.Errorf("rename: got %+v, want write=false duplicate=true dest=%q", res, want)
+	}
+}
+
+func TestResolveCollisionRenameExhausted(t *testing.T) {
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "IMG1.jpg")
+	ext := filepath.Ext(dest)
+	base := dest[:len(dest)-len(ext)]
+
+	ledger := make(destLedger)
+	for i := 1; i <= maxCollisionRetries; i++ {
+		ledger.record(fmt.Sprintf("%s-%d%s", base, i, ext), "taken")
+	}
+
+	if _, err := resolveCollision(ledger, collisionRename, dest, "hash-b"); err == nil {
+		t.Error("expected an error once every rename slot is taken, got nil")
+	}
+}
+
+func TestPathExists(t *testing.T) {
+	dir := t.TempDir()
+	present := filepath.Join(dir, "present.jpg")
+	if err := os.WriteFile(present, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !pathExists(present) {
+		t.Errorf("pathExists(%q) = false, want true", present)
+	}
+	if pathExists(filepath.Join(dir, "missing.jpg")) {
+		t.Error("pathExists on a missing path = true, want false")
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// layout selects how images are arranged under the destination root.
+type layout string
+
+const (
+	// layoutFlat is the original behaviour: dest/YYYY/MM/DD/name, one copy
+	// of the bytes per source file.
+	layoutFlat layout = "flat"
+	// layoutContent stores each image once in the hash-sharded content tree
+	// and skips the date tree entirely.
+	layoutContent layout = "content"
+	// layoutBoth stores each image once in the content tree and links it
+	// into the date tree.
+	layoutBoth layout = "both"
+)
+
+func parseLayout(s string) (layout, error) {
+	switch layout(s) {
+	case layoutFlat, layoutContent, layoutBoth:
+		return layout(s), nil
+	default:
+		return "", fmt.Errorf("unknown -layout %q (want %q, %q, or %q)", s, layoutFlat, layoutContent, layoutBoth)
+	}
+}
+
+// PrepOutput pre-creates the 256 hash-sharded content buckets under
+// destRoot/content, one per first-byte value, so the move stage never has
+// to create them on the hot path.
+func PrepOutput(destRoot string, l layout) error {
+	if l == layoutFlat {
+		return nil
+	}
+	for i := 0; i < 256; i++ {
+		bucket := filepath.Join(destRoot, "content", fmt.Sprintf("%02x", i))
+		if err := os.MkdirAll(bucket, 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// contentPath returns the hash-sharded path for a file with the given
+// content hash and extension: the first two hex chars of the hash select
+// one of 256 top-level buckets, the rest of the hash names the file.
+func contentPath(destRoot, hash, ext string) string {
+	return filepath.Join(destRoot, "content", hash[:2], hash[2:]+ext)
+}
+
+// linkIntoContent makes dateDest resolve to contentDest, preferring a
+// hardlink (only possible on the same filesystem) and falling back to a
+// relative symlink otherwise. Any pre-existing entry at dateDest - e.g. an
+// -on-collision=overwrite target - is removed first, since Link/Symlink
+// both fail with "file exists" otherwise.
+func linkIntoContent(dateDest, contentDest string) error {
+	if err := os.MkdirAll(filepath.Dir(dateDest), 0755); err != nil {
+		return err
+	}
+	if err := os.Remove(dateDest); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Link(contentDest, dateDest); err == nil {
+		return nil
+	}
+	target, err := filepath.Rel(filepath.Dir(dateDest), contentDest)
+	if err != nil {
+		target = contentDest
+	}
+	return os.Symlink(target, dateDest)
+}
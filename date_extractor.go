@@ -0,0 +1,121 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"regexp"
+	"time"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// errNoDate is returned when every extractor in a chain declined to produce
+// a date. In practice this shouldn't happen since mtimeExtractor always
+// succeeds, but a caller could supply a chain without it.
+var errNoDate = errors.New("no extractor could determine a date")
+
+// DateExtractor attempts to determine when a file was captured. It reports
+// ok=false (with a nil error) when the file simply doesn't carry the kind of
+// metadata this extractor looks for, so the caller can fall through to the
+// next extractor in the chain. A non-nil error indicates the file itself
+// couldn't be read. path is resolved against fsys, so a chain can be driven
+// by an fstest.MapFS fixture as easily as a real source tree.
+type DateExtractor interface {
+	ExtractDate(fsys fs.FS, path string) (t time.Time, ok bool, err error)
+}
+
+// videoExts are routed to the video-specific extractor chain; everything
+// else is treated as a still image.
+var videoExts = map[string]bool{
+	"mov": true,
+	"mp4": true,
+	"m4v": true,
+}
+
+// extractorsFor returns the ordered chain of extractors to try for a file
+// with the given (lowercase, dot-stripped) extension. mtimeExtractor always
+// succeeds, so it anchors every chain as the fallback of last resort.
+func extractorsFor(ext string) []DateExtractor {
+	if videoExts[ext] {
+		return []DateExtractor{quicktimeExtractor{}, xmpExtractor{}, mtimeExtractor{}}
+	}
+	return []DateExtractor{exifExtractor{}, xmpExtractor{}, mtimeExtractor{}}
+}
+
+// extractDate runs extractors in order and returns the first one that
+// successfully determines a date.
+func extractDate(fsys fs.FS, path string, extractors []DateExtractor) (time.Time, error) {
+	for _, e := range extractors {
+		t, ok, err := e.ExtractDate(fsys, path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if ok {
+			return t, nil
+		}
+	}
+	return time.Time{}, errNoDate
+}
+
+// exifExtractor reads the EXIF DateTimeOriginal tag embedded in the file
+// itself. This is the extractor the tool originally relied on exclusively.
+type exifExtractor struct{}
+
+func (exifExtractor) ExtractDate(fsys fs.FS, path string) (time.Time, bool, error) {
+	f, err := fsys.Open(path)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	defer f.Close()
+
+	x, err := exif.Decode(f)
+	if err != nil {
+		// No EXIF data is the common case for screenshots, PNGs, etc. - not
+		// an error, just a reason to try the next extractor.
+		return time.Time{}, false, nil
+	}
+	date, err := x.DateTime()
+	if err != nil {
+		return time.Time{}, false, nil
+	}
+	return date, true, nil
+}
+
+// xmpDatePattern matches the exif:DateTimeOriginal or xmp:CreateDate value
+// in a sidecar .xmp file, e.g. exif:DateTimeOriginal="2020-05-01T10:00:00".
+var xmpDatePattern = regexp.MustCompile(`(?:DateTimeOriginal|CreateDate)[>="]+([0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9]{2}:[0-9]{2}:[0-9]{2})`)
+
+// xmpExtractor reads the date from a "<name>.xmp" sidecar file next to the
+// image, as written by tools like Lightroom and digiKam.
+type xmpExtractor struct{}
+
+func (xmpExtractor) ExtractDate(fsys fs.FS, path string) (time.Time, bool, error) {
+	data, err := fs.ReadFile(fsys, path+".xmp")
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return time.Time{}, false, nil
+		}
+		return time.Time{}, false, err
+	}
+	m := xmpDatePattern.FindSubmatch(data)
+	if m == nil {
+		return time.Time{}, false, nil
+	}
+	date, err := time.Parse("2006-01-02T15:04:05", string(m[1]))
+	if err != nil {
+		return time.Time{}, false, nil
+	}
+	return date, true, nil
+}
+
+// mtimeExtractor falls back to the filesystem modification time. It always
+// succeeds unless the file can't be stat'd, so it belongs last in a chain.
+type mtimeExtractor struct{}
+
+func (mtimeExtractor) ExtractDate(fsys fs.FS, path string) (time.Time, bool, error) {
+	fi, err := fs.Stat(fsys, path)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return fi.ModTime(), true, nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExtractorsForRoutesByExtension(t *testing.T) {
+	for _, ext := range []string{"mov", "mp4", "m4v"} {
+		chain := extractorsFor(ext)
+		if len(chain) == 0 {
+			t.Fatalf("extractorsFor(%q) returned an empty chain", ext)
+		}
+		if _, ok := chain[0].(quicktimeExtractor); !ok {
+			t.Errorf("extractorsFor(%q)[0] = %T, want quicktimeExtractor", ext, chain[0])
+		}
+	}
+
+	for _, ext := range []string{"jpg", "jpeg", "png", "heic"} {
+		chain := extractorsFor(ext)
+		if len(chain) == 0 {
+			t.Fatalf("extractorsFor(%q) returned an empty chain", ext)
+		}
+		if _, ok := chain[0].(exifExtractor); !ok {
+			t.Errorf("extractorsFor(%q)[0] = %T, want exifExtractor", ext, chain[0])
+		}
+	}
+
+	for _, chain := range [][]DateExtractor{extractorsFor("jpg"), extractorsFor("mov")} {
+		if _, ok := chain[len(chain)-1].(mtimeExtractor); !ok {
+			t.Errorf("chain's last extractor = %T, want mtimeExtractor", chain[len(chain)-1])
+		}
+	}
+}
+
+func TestExtractDateFallsThroughToMtime(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "screenshot.png")
+	if err := os.WriteFile(path, []byte("not a real png"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2019, time.June, 15, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := extractDate(os.DirFS(dir), "screenshot.png", extractorsFor("png"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !got.Equal(mtime) {
+		t.Errorf("extractDate = %v, want %v", got, mtime)
+	}
+}
+
+func TestExtractDateAllDeclineReturnsErrNoDate(t *testing.T) {
+	if _, err := extractDate(os.DirFS(t.TempDir()), "whatever", nil); err != errNoDate {
+		t.Errorf("extractDate with an empty chain = %v, want %v", err, errNoDate)
+	}
+}
+
+func TestXmpExtractorReadsSidecar(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "IMG1.jpg"), []byte("jpeg bytes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sidecar := `<rdf:Description exif:DateTimeOriginal="2020-05-01T10:00:00"/>`
+	if err := os.WriteFile(filepath.Join(dir, "IMG1.jpg.xmp"), []byte(sidecar), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok, err := (xmpExtractor{}).ExtractDate(os.DirFS(dir), "IMG1.jpg")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ok {
+		t.Fatal("ExtractDate reported ok=false for a file with a valid sidecar")
+	}
+	want := time.Date(2020, time.May, 1, 10, 0, 0, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Errorf("ExtractDate = %v, want %v", got, want)
+	}
+}
+
+func TestXmpExtractorNoSidecar(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "IMG1.jpg"), []byte("jpeg bytes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, ok, err := (xmpExtractor{}).ExtractDate(os.DirFS(dir), "IMG1.jpg")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ok {
+		t.Error("ExtractDate reported ok=true with no sidecar file present")
+	}
+}
+
+func TestXmpExtractorMalformedSidecar(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "IMG1.jpg"), []byte("jpeg bytes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "IMG1.jpg.xmp"), []byte("no date in here"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, ok, err := (xmpExtractor{}).ExtractDate(os.DirFS(dir), "IMG1.jpg")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ok {
+		t.Error("ExtractDate reported ok=true for a sidecar with no recognizable date")
+	}
+}
+
+func TestMtimeExtractorUsesModTime(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "IMG1.jpg")
+	if err := os.WriteFile(path, []byte("jpeg bytes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2018, time.December, 25, 9, 30, 0, 0, time.UTC)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok, err := (mtimeExtractor{}).ExtractDate(os.DirFS(dir), "IMG1.jpg")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ok {
+		t.Fatal("ExtractDate reported ok=false")
+	}
+	if !got.Equal(mtime) {
+		t.Errorf("ExtractDate = %v, want %v", got, mtime)
+	}
+}
+
+func TestMtimeExtractorMissingFile(t *testing.T) {
+	if _, ok, err := (mtimeExtractor{}).ExtractDate(os.DirFS(t.TempDir()), "missing.jpg"); err == nil || ok {
+		t.Errorf("ExtractDate on a missing file = ok=%v, err=%v; want ok=false, non-nil err", ok, err)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// hashFile computes the SHA-256 content digest of f and stores it on image
+// as a lowercase hex string. f must be freshly opened and unread, since this
+// reads it start to end without rewinding.
+func hashFile(image *Image, f fs.File) error {
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	image.hash = hex.EncodeToString(h.Sum(nil))
+	return nil
+}
+
+// hashPath opens path and returns its SHA-256 content digest as a lowercase
+// hex string.
+func hashPath(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
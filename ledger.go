@@ -0,0 +1,31 @@
+package main
+
+// destLedger tracks destinations this run has already decided about - dest
+// path to content hash - so that -dry-run (which never touches the real
+// filesystem) reports the same duplicates and collisions a real run would
+// produce. moveWorker owns the only instance and runs single-threaded, so
+// the map needs no locking.
+type destLedger map[string]string
+
+// stateOf reports what's known about dest, preferring this run's ledger
+// and falling back to the real filesystem when dest hasn't been decided on
+// yet.
+func (l destLedger) stateOf(dest string) (hash string, exists bool, err error) {
+	if h, ok := l[dest]; ok {
+		return h, true, nil
+	}
+	if !pathExists(dest) {
+		return "", false, nil
+	}
+	h, err := hashPath(dest)
+	if err != nil {
+		return "", false, err
+	}
+	return h, true, nil
+}
+
+// record notes that dest now holds content with the given hash, whether or
+// not that was actually written to disk.
+func (l destLedger) record(dest, hash string) {
+	l[dest] = hash
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDestLedgerStateOfUnknownPath(t *testing.T) {
+	ledger := make(destLedger)
+	dir := t.TempDir()
+
+	_, exists, err := ledger.stateOf(filepath.Join(dir, "missing.jpg"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if exists {
+		t.Error("stateOf on an unknown, nonexistent path reported exists=true")
+	}
+}
+
+func TestDestLedgerStateOfRecorded(t *testing.T) {
+	ledger := make(destLedger)
+	dest := filepath.Join(t.TempDir(), "IMG1.jpg")
+	ledger.record(dest, "hash-a")
+
+	hash, exists, err := ledger.stateOf(dest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !exists || hash != "hash-a" {
+		t.Errorf("stateOf(%q) = %q, %v; want %q, true", dest, hash, exists, "hash-a")
+	}
+}
+
+func TestDestLedgerStateOfFallsBackToDisk(t *testing.T) {
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "IMG1.jpg")
+	if err := os.WriteFile(dest, []byte("on-disk content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	want, err := hashPath(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A fresh ledger knows nothing about dest, so stateOf must hash the
+	// real file rather than reporting it as unclaimed.
+	ledger := make(destLedger)
+	hash, exists, err := ledger.stateOf(dest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !exists || hash != want {
+		t.Errorf("stateOf(%q) = %q, %v; want %q, true", dest, hash, exists, want)
+	}
+}
+
+func TestDestLedgerRecordOverridesDisk(t *testing.T) {
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "IMG1.jpg")
+	if err := os.WriteFile(dest, []byte("stale on-disk content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ledger := make(destLedger)
+	ledger.record(dest, "fresh-hash")
+
+	hash, exists, err := ledger.stateOf(dest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !exists || hash != "fresh-hash" {
+		t.Errorf("stateOf(%q) = %q, %v; want %q, true (ledger should win over disk)", dest, hash, exists, "fresh-hash")
+	}
+}
@@ -4,13 +4,32 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
-	"path/filepath"
-
-	"github.com/rwcarlsen/goexif/exif"
-	"gopkg.in/h2non/filetype.v1"
+	"runtime"
+	"strings"
+	"sync"
 )
 
+// defaultExts is the set of extensions sort_images looks for when -ext isn't
+// given: common still-image formats plus the handful of video containers
+// the QuickTime date extractor understands.
+const defaultExts = "jpg,jpeg,png,gif,heic,mov,mp4,m4v"
+
+// parseExts turns a comma-separated, optionally dot-prefixed extension list
+// into a lowercased lookup set.
+func parseExts(s string) map[string]bool {
+	exts := make(map[string]bool)
+	for _, e := range strings.Split(s, ",") {
+		e = strings.ToLower(strings.TrimSpace(e))
+		e = strings.TrimPrefix(e, ".")
+		if e != "" {
+			exts[e] = true
+		}
+	}
+	return exts
+}
+
 // Image holds information about an image
 type Image struct {
 	name  string
@@ -18,15 +37,37 @@ type Image struct {
 	year  int
 	month int
 	day   int
+	hash  string
 }
 
 func main() {
 	sourceFlag := flag.String("source", "./", "Folder with unorganised images. Must be an existing folder.")
 	destFlag := flag.String("destination", "", "Folder to move the images into. The folder is created if it does not exist. (Required)")
 	dryRunFlag := flag.Bool("dry-run", true, "Set to false to actually make changes.")
+	workersFlag := flag.Int("workers", runtime.NumCPU(), "Number of parallel workers used to parse image metadata.")
+	layoutFlag := flag.String("layout", string(layoutFlat), "Destination layout: flat, content, or both.")
+	extFlag := flag.String("ext", defaultExts, "Comma-separated list of file extensions to treat as images/videos.")
+	collisionFlag := flag.String("on-collision", string(collisionRename), "What to do when two source files map to the same destination name: skip, rename, overwrite, or error.")
 	required := []string{"destination"}
 	flag.Parse()
 
+	if *workersFlag < 1 {
+		fmt.Fprintf(os.Stderr, "-workers must be at least 1 (got %d)\n", *workersFlag)
+		os.Exit(2)
+	}
+
+	l, err := parseLayout(*layoutFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	policy, err := parseCollisionPolicy(*collisionFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	exts := parseExts(*extFlag)
+
 	seen := make(map[string]bool)
 	flag.Visit(func(f *flag.Flag) { seen[f.Name] = true })
 
@@ -57,132 +98,49 @@ func main() {
 		os.Exit(2)
 	}
 
-	// Suppress warnings about variables not being used
-	_ = *sourceFlag
-	_ = *destFlag
-	_ = *dryRunFlag
-
-	// Traverse source and find all images in the directory
-	images, err := getImages(*sourceFlag)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error walking the path %q: %v\n", *sourceFlag, err)
-	}
-
-	// Find out when each image is taken
-	for idx := range images {
-		err := getDate(&images[idx])
-		if err != nil {
-			continue
+	if !*dryRunFlag {
+		if err := PrepOutput(*destFlag, l); err != nil {
+			fmt.Fprintf(os.Stderr, "Something went wrong while preparing %s: %v\n", *destFlag, err)
+			os.Exit(1)
 		}
 	}
 
-	// Create folder structure
-	for _, image := range images {
-		newPath := filepath.Join(*destFlag, fmt.Sprintf("%04d/%02d/%02d", image.year, image.month, image.day))
-		if _, err := os.Stat(newPath); os.IsNotExist(err) {
-			err := os.MkdirAll(newPath, 0755)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Something went wrong while creating the directory %s: %v\n", newPath, err)
-				os.Exit(1)
-			}
-		}
-	}
-
-	// Copy images to date folders
-	for _, image := range images {
-		newPath := filepath.Join(*destFlag, fmt.Sprintf("%04d/%02d/%02d/%s", image.year, image.month, image.day, image.name))
-		err := copyFile(image.path, newPath)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Something went wrong while copying the image %s to %s: %v\n", image.path, newPath, err)
-			continue
-		}
-	}
-}
-
-func getDate(image *Image) error {
-	f, err := os.Open(image.path)
-	defer f.Close()
-	if err != nil {
-		return err
-	}
+	// Source: walk the tree and stream candidate paths.
+	srcFS, paths, walkErr := walkSource(*sourceFlag)
 
-	x, err := exif.Decode(f)
-	if err != nil {
-		return err
-	}
+	stats := &Stats{}
 
-	date, err := x.DateTime()
-	if err != nil {
-		return err
+	// Parse: a pool of workers hashes each image and extracts its date.
+	parsed := make(chan Image)
+	var parseWg sync.WaitGroup
+	for i := 0; i < *workersFlag; i++ {
+		parseWg.Add(1)
+		go parseWorker(srcFS, paths, parsed, exts, stats, &parseWg)
 	}
+	go func() {
+		parseWg.Wait()
+		close(parsed)
+	}()
 
-	image.year = date.Year()
-	image.month = int(date.Month())
-	image.day = date.Day()
-	return nil
-}
+	// Move: a single writer applies the dedupe check and copies the file.
+	var moveWg sync.WaitGroup
+	moveWg.Add(1)
+	go moveWorker(srcFS, parsed, *destFlag, l, policy, *dryRunFlag, stats, &moveWg)
+	moveWg.Wait()
 
-func getImages(path string) ([]Image, error) {
-	var images []Image
-	err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if info.IsDir() {
-			return nil
-		}
-		file, err := os.Open(path)
-		if err != nil {
-			return err
-		}
-		defer file.Close()
-		head := make([]byte, 261)
-		file.Read(head)
-		if filetype.IsImage(head) {
-			absPath, err := filepath.Abs(path)
-			if err != nil {
-				return err
-			}
-			images = append(images, Image{
-				path: absPath,
-				name: info.Name(),
-			})
-		}
-		return nil
-	})
-	if err != nil {
-		return nil, err
+	if err := walkErr(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error walking the path %q: %v\n", *sourceFlag, err)
 	}
-	return images, nil
-}
 
-func copyFile(src, dst string) error {
-	sfi, err := os.Stat(src)
-	if err != nil {
-		return err
-	}
-	if !sfi.Mode().IsRegular() {
-		return fmt.Errorf("CopyFile: non-regular source file %s (%q)", sfi.Name(), sfi.Mode().String())
-	}
-	dfi, err := os.Stat(dst)
-	if err != nil {
-		if !os.IsNotExist(err) {
-			return err
-		}
-	} else {
-		if !(dfi.Mode().IsRegular()) {
-			return fmt.Errorf("CopyFile: non-regular destination file %s (%q)", dfi.Name(), dfi.Mode().String())
-		}
-		if os.SameFile(sfi, dfi) {
-			return err
-		}
-	}
-	err = copyFileContents(src, dst)
-	return err
+	fmt.Println(stats.String())
 }
 
-func copyFileContents(src, dst string) error {
-	in, err := os.Open(src)
+// copyFromFS copies src, opened from fsys, to dst on the real filesystem.
+// fsys lets the source side of a copy be backed by anything - a real source
+// tree in production, an fstest.MapFS fixture in a test - while dst, always
+// somewhere under the destination root, is written with the real os package.
+func copyFromFS(fsys fs.FS, src, dst string) (err error) {
+	in, err := fsys.Open(src)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// walkFS walks fsys from root using fs.WalkDir, streaming the path of every
+// entry that isn't a directory (symlinks and other non-regular entries
+// included) on the returned channel, which is closed once the walk
+// completes. The returned errFn must only be called after the channel has
+// been drained, at which point it reports any error the walk encountered.
+// Unlike filepath.Walk, fs.WalkDir only stats an entry when the callback
+// actually needs its info, so it avoids an Lstat per entry.
+func walkFS(fsys fs.FS, root string) (paths <-chan string, errFn func() error) {
+	out := make(chan string)
+	var walkErr error
+	go func() {
+		defer close(out)
+		walkErr = fs.WalkDir(fsys, root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			out <- path
+			return nil
+		})
+	}()
+	return out, func() error { return walkErr }
+}
+
+// walkSource walks the real directory root on disk, streaming the path
+// (relative to root) of every entry that isn't a directory on the returned
+// channel, along with the
+// fs.FS the rest of the pipeline should read that same file through. It's a
+// thin os.DirFS wrapper around walkFS.
+func walkSource(root string) (fsys fs.FS, paths <-chan string, errFn func() error) {
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		out := make(chan string)
+		close(out)
+		return nil, out, func() error { return err }
+	}
+
+	fsys = os.DirFS(absRoot)
+	paths, errFn = walkFS(fsys, ".")
+	return fsys, paths, errFn
+}
+
+// extOf returns path's extension, lowercased and without the leading dot.
+func extOf(path string) string {
+	return strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+}
+
+// parseImage inspects path within fsys, filtering out anything whose
+// extension isn't in exts, and returns a fully populated Image (date and
+// content hash) for the rest. The date is determined by the extractor chain
+// appropriate for the file's extension, which always succeeds via the mtime
+// fallback. Reading everything through fsys rather than the real filesystem
+// directly means this - and everything downstream that takes an fsys - can
+// be driven by an fstest.MapFS fixture in a test as easily as a real source
+// tree.
+func parseImage(fsys fs.FS, path string, exts map[string]bool) (image Image, ok bool, err error) {
+	ext := extOf(path)
+	if !exts[ext] {
+		return Image{}, false, nil
+	}
+
+	f, err := fsys.Open(path)
+	if err != nil {
+		return Image{}, false, err
+	}
+	defer f.Close()
+
+	image = Image{path: path, name: filepath.Base(path)}
+
+	date, err := extractDate(fsys, path, extractorsFor(ext))
+	if err != nil {
+		return Image{}, false, err
+	}
+	image.year = date.Year()
+	image.month = int(date.Month())
+	image.day = date.Day()
+
+	if err := hashFile(&image, f); err != nil {
+		return Image{}, false, err
+	}
+	return image, true, nil
+}
+
+// parseWorker reads candidate paths from in and sends every fully parsed
+// image on out, until in is closed. Errors while parsing a single path are
+// logged and counted rather than aborting the pipeline.
+func parseWorker(fsys fs.FS, in <-chan string, out chan<- Image, exts map[string]bool, stats *Stats, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for path := range in {
+		image, ok, err := parseImage(fsys, path, exts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Something went wrong while parsing %s: %v\n", path, err)
+			stats.recordError()
+			continue
+		}
+		if !ok {
+			continue
+		}
+		out <- image
+	}
+}
+
+// moveWorker writes each image it receives into destRoot under its
+// YYYY/MM/DD directory, skipping images whose destination already holds a
+// file with the same content hash. Under layoutContent/layoutBoth the image
+// is instead (or also) stored once in the hash-sharded content tree. Image
+// bytes are read from fsys; destRoot is always a real directory on disk.
+func moveWorker(fsys fs.FS, in <-chan Image, destRoot string, l layout, policy collisionPolicy, dryRun bool, stats *Stats, wg *sync.WaitGroup) {
+	defer wg.Done()
+	ledger := make(destLedger)
+	for image := range in {
+		var err error
+		switch l {
+		case layoutFlat:
+			err = moveFlat(fsys, image, destRoot, ledger, policy, dryRun, stats)
+		case layoutContent:
+			err = moveContent(fsys, image, destRoot, ledger, dryRun, stats)
+		case layoutBoth:
+			err = moveBoth(fsys, image, destRoot, ledger, policy, dryRun, stats)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Something went wrong while moving %s: %v\n", image.path, err)
+			stats.recordError()
+		}
+	}
+}
+
+// moveFlat writes image straight into its dest/YYYY/MM/DD directory, naming
+// a genuine collision (same path, different content) according to policy.
+func moveFlat(fsys fs.FS, image Image, destRoot string, ledger destLedger, policy collisionPolicy, dryRun bool, stats *Stats) error {
+	dir := filepath.Join(destRoot, fmt.Sprintf("%04d/%02d/%02d", image.year, image.month, image.day))
+	dest := filepath.Join(dir, image.name)
+
+	dest, handled, err := settleDest(dest, image.hash, ledger, policy, stats)
+	if err != nil {
+		return err
+	}
+	if handled {
+		return nil
+	}
+
+	if dryRun {
+		stats.recordCreated()
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := copyFromFS(fsys, image.path, dest); err != nil {
+		return err
+	}
+	stats.recordCreated()
+	return nil
+}
+
+// settleDest checks whether dest is already taken - by an earlier file in
+// this same run, per ledger, or on disk - and, if so, resolves the
+// collision according to policy. It returns the path the caller should
+// actually write to, and handled=true when the caller has nothing left to
+// do (the outcome - duplicate or skipped - has already been recorded).
+func settleDest(dest, hash string, ledger destLedger, policy collisionPolicy, stats *Stats) (resolved string, handled bool, err error) {
+	existingHash, exists, err := ledger.stateOf(dest)
+	if err != nil {
+		return "", false, err
+	}
+	if !exists {
+		ledger.record(dest, hash)
+		return dest, false, nil
+	}
+	if existingHash == hash {
+		stats.recordDuplicate()
+		return "", true, nil
+	}
+
+	res, err := resolveCollision(ledger, policy, dest, hash)
+	if err != nil {
+		return "", false, err
+	}
+	if !res.write {
+		if res.duplicate {
+			stats.recordDuplicate()
+		} else {
+			stats.recordSkipped()
+		}
+		return "", true, nil
+	}
+	ledger.record(res.dest, hash)
+	return res.dest, false, nil
+}
+
+// moveContent writes image into the hash-sharded content tree only,
+// deduplicating by content hash.
+func moveContent(fsys fs.FS, image Image, destRoot string, ledger destLedger, dryRun bool, stats *Stats) error {
+	_, created, err := storeContent(fsys, image, destRoot, ledger, dryRun)
+	if err != nil {
+		return err
+	}
+	if created {
+		stats.recordCreated()
+	} else {
+		stats.recordDuplicate()
+	}
+	return nil
+}
+
+// moveBoth links image's date-tree path to its content-tree copy, naming a
+// genuine collision (same date-tree path, a different hash already linked
+// there) according to policy. The content store always runs first - it's
+// addressed by hash and can't collide, so storing it is always safe - and
+// only the date-tree *link* is gated on settleDest's outcome, so a
+// skip/error verdict there can at worst leave the content unlinked from the
+// date tree, never unstored.
+func moveBoth(fsys fs.FS, image Image, destRoot string, ledger destLedger, policy collisionPolicy, dryRun bool, stats *Stats) error {
+	dir := filepath.Join(destRoot, fmt.Sprintf("%04d/%02d/%02d", image.year, image.month, image.day))
+	dateDest := filepath.Join(dir, image.name)
+
+	contentDest, created, err := storeContent(fsys, image, destRoot, ledger, dryRun)
+	if err != nil {
+		return err
+	}
+
+	dateDest, handled, err := settleDest(dateDest, image.hash, ledger, policy, stats)
+	if err != nil {
+		return err
+	}
+	if handled {
+		return nil
+	}
+
+	if !dryRun {
+		if err := linkIntoContent(dateDest, contentDest); err != nil {
+			return err
+		}
+	}
+
+	if created {
+		stats.recordCreated()
+	} else {
+		stats.recordDuplicate()
+	}
+	return nil
+}
+
+// storeContent writes image's bytes into the content tree unless a file
+// with the same hash is already stored there - per this run's ledger or on
+// disk - and reports whether it did.
+func storeContent(fsys fs.FS, image Image, destRoot string, ledger destLedger, dryRun bool) (dest string, created bool, err error) {
+	dest = contentPath(destRoot, image.hash, filepath.Ext(image.name))
+
+	_, exists, err := ledger.stateOf(dest)
+	if err != nil {
+		return dest, false, err
+	}
+	if exists {
+		return dest, false, nil
+	}
+	if !dryRun {
+		if err := copyFromFS(fsys, image.path, dest); err != nil {
+			return dest, false, err
+		}
+	}
+	ledger.record(dest, image.hash)
+	return dest, true, nil
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// TestMoveBothSkipStillStoresContent guards against a regression where a
+// genuine date-tree collision under -on-collision=skip caused moveBoth to
+// return before the colliding file's bytes ever reached the content tree -
+// silently losing a unique file's content instead of merely skipping its
+// date-tree link.
+func TestMoveBothSkipStillStoresContent(t *testing.T) {
+	srcDir := t.TempDir()
+	destRoot := t.TempDir()
+	if err := PrepOutput(destRoot, layoutBoth); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(srcDir, "a.jpg"), []byte("first"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "b.jpg"), []byte("second"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	srcFS := os.DirFS(srcDir)
+	hash1, err := hashPath(filepath.Join(srcDir, "a.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash2, err := hashPath(filepath.Join(srcDir, "b.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Same destination name, different content: a genuine collision.
+	img1 := Image{path: "a.jpg", name: "IMG1.jpg", year: 2020, month: 1, day: 1, hash: hash1}
+	img2 := Image{path: "b.jpg", name: "IMG1.jpg", year: 2020, month: 1, day: 1, hash: hash2}
+
+	ledger := make(destLedger)
+	stats := &Stats{}
+
+	if err := moveBoth(srcFS, img1, destRoot, ledger, collisionSkip, false, stats); err != nil {
+		t.Fatalf("first moveBoth: %v", err)
+	}
+	if err := moveBoth(srcFS, img2, destRoot, ledger, collisionSkip, false, stats); err != nil {
+		t.Fatalf("second moveBoth: %v", err)
+	}
+
+	contentDest := contentPath(destRoot, hash2, ".jpg")
+	data, err := os.ReadFile(contentDest)
+	if err != nil {
+		t.Fatalf("second file's content was never stored at %s: %v", contentDest, err)
+	}
+	if string(data) != "second" {
+		t.Errorf("content at %s = %q, want %q", contentDest, data, "second")
+	}
+
+	if got, want := stats.String(), "created=1 duplicate=0 skipped=1 error=0"; got != want {
+		t.Errorf("stats = %q, want %q", got, want)
+	}
+}
+
+// TestParseAndMoveFromMapFS drives parseImage and moveFlat entirely off an
+// in-memory fstest.MapFS fixture rather than a real source directory,
+// exercising the extractor chain's mtime fallback along the way. Only the
+// destination - always a real directory - touches the real filesystem.
+func TestParseAndMoveFromMapFS(t *testing.T) {
+	mtime := time.Date(2021, time.March, 4, 0, 0, 0, 0, time.UTC)
+	srcFS := fstest.MapFS{
+		"photo.jpg": &fstest.MapFile{
+			Data:    []byte("not a real jpeg, just some bytes"),
+			ModTime: mtime,
+		},
+	}
+
+	image, ok, err := parseImage(srcFS, "photo.jpg", map[string]bool{"jpg": true})
+	if err != nil {
+		t.Fatalf("parseImage: %v", err)
+	}
+	if !ok {
+		t.Fatal("parseImage reported ok=false for a matching extension")
+	}
+	if image.year != 2021 || image.month != 3 || image.day != 4 {
+		t.Errorf("image date = %04d-%02d-%02d, want 2021-03-04", image.year, image.month, image.day)
+	}
+
+	destRoot := t.TempDir()
+	stats := &Stats{}
+	ledger := make(destLedger)
+	if err := moveFlat(srcFS, image, destRoot, ledger, collisionRename, false, stats); err != nil {
+		t.Fatalf("moveFlat: %v", err)
+	}
+
+	dest := filepath.Join(destRoot, "2021/03/04/photo.jpg")
+	data, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatalf("photo.jpg was not written to %s: %v", dest, err)
+	}
+	if string(data) != "not a real jpeg, just some bytes" {
+		t.Errorf("content at %s = %q, want the fixture's bytes", dest, data)
+	}
+}
+
+func TestSettleDestUnclaimedRecordsNothing(t *testing.T) {
+	ledger := make(destLedger)
+	stats := &Stats{}
+
+	dest, handled, err := settleDest("/dest/IMG1.jpg", "hash-a", ledger, collisionSkip, stats)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if handled || dest != "/dest/IMG1.jpg" {
+		t.Errorf("settleDest = %q, %v; want %q, false", dest, handled, "/dest/IMG1.jpg")
+	}
+	if got, want := stats.String(), "created=0 duplicate=0 skipped=0 error=0"; got != want {
+		t.Errorf("stats = %q, want %q", got, want)
+	}
+}
+
+func TestSettleDestSameHashRecordsDuplicate(t *testing.T) {
+	ledger := make(destLedger)
+	ledger.record("/dest/IMG1.jpg", "hash-a")
+	stats := &Stats{}
+
+	_, handled, err := settleDest("/dest/IMG1.jpg", "hash-a", ledger, collisionSkip, stats)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !handled {
+		t.Error("settleDest: handled=false for a same-hash dest, want true")
+	}
+	if got, want := stats.String(), "created=0 duplicate=1 skipped=0 error=0"; got != want {
+		t.Errorf("stats = %q, want %q", got, want)
+	}
+}
+
+func TestSettleDestCollisionDefersToPolicy(t *testing.T) {
+	ledger := make(destLedger)
+	ledger.record("/dest/IMG1.jpg", "hash-a")
+	stats := &Stats{}
+
+	dest, handled, err := settleDest("/dest/IMG1.jpg", "hash-b", ledger, collisionRename, stats)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if handled {
+		t.Error("settleDest: handled=true for a rename that found a free slot, want false")
+	}
+	if want := "/dest/IMG1-1.jpg"; dest != want {
+		t.Errorf("settleDest dest = %q, want %q", dest, want)
+	}
+}
+
+// TestStoreContentDedupesAcrossCalls checks that a second storeContent call
+// for the same hash is recognized as a content-level duplicate via the
+// ledger, without re-reading the source bytes.
+func TestStoreContentDedupesAcrossCalls(t *testing.T) {
+	srcDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(srcDir, "a.jpg"), []byte("bytes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	srcFS := os.DirFS(srcDir)
+	hash, err := hashPath(filepath.Join(srcDir, "a.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	destRoot := t.TempDir()
+	if err := PrepOutput(destRoot, layoutContent); err != nil {
+		t.Fatal(err)
+	}
+	image := Image{path: "a.jpg", name: "a.jpg", hash: hash}
+	ledger := make(destLedger)
+
+	dest, created, err := storeContent(srcFS, image, destRoot, ledger, false)
+	if err != nil {
+		t.Fatalf("first storeContent: %v", err)
+	}
+	if !created {
+		t.Error("first storeContent: created=false, want true")
+	}
+
+	dest2, created2, err := storeContent(srcFS, image, destRoot, ledger, false)
+	if err != nil {
+		t.Fatalf("second storeContent: %v", err)
+	}
+	if created2 {
+		t.Error("second storeContent: created=true, want false (already in ledger)")
+	}
+	if dest2 != dest {
+		t.Errorf("second storeContent dest = %q, want %q", dest2, dest)
+	}
+}
+
+func TestMoveContentRecordsCreatedThenDuplicate(t *testing.T) {
+	srcDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(srcDir, "a.jpg"), []byte("bytes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	srcFS := os.DirFS(srcDir)
+	hash, err := hashPath(filepath.Join(srcDir, "a.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	destRoot := t.TempDir()
+	if err := PrepOutput(destRoot, layoutContent); err != nil {
+		t.Fatal(err)
+	}
+	image := Image{path: "a.jpg", name: "a.jpg", hash: hash}
+	ledger := make(destLedger)
+	stats := &Stats{}
+
+	if err := moveContent(srcFS, image, destRoot, ledger, false, stats); err != nil {
+		t.Fatalf("first moveContent: %v", err)
+	}
+	if err := moveContent(srcFS, image, destRoot, ledger, false, stats); err != nil {
+		t.Fatalf("second moveContent: %v", err)
+	}
+
+	if got, want := stats.String(), "created=1 duplicate=1 skipped=0 error=0"; got != want {
+		t.Errorf("stats = %q, want %q", got, want)
+	}
+}
+
+// TestParseWorkerSkipsErrorsAndContinues checks that parseWorker logs and
+// counts an error for one bad path but keeps processing the rest of the
+// channel rather than aborting.
+func TestParseWorkerSkipsErrorsAndContinues(t *testing.T) {
+	srcDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(srcDir, "ok.jpg"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	srcFS := os.DirFS(srcDir)
+
+	paths := make(chan string, 2)
+	paths <- "missing.jpg"
+	paths <- "ok.jpg"
+	close(paths)
+
+	out := make(chan Image, 2)
+	stats := &Stats{}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go parseWorker(srcFS, paths, out, map[string]bool{"jpg": true}, stats, &wg)
+	wg.Wait()
+	close(out)
+
+	var got []Image
+	for image := range out {
+		got = append(got, image)
+	}
+	if len(got) != 1 || got[0].name != "ok.jpg" {
+		t.Errorf("parseWorker sent %+v, want exactly the parsed \"ok.jpg\" image", got)
+	}
+	if want, stat := "created=0 duplicate=0 skipped=0 error=1", stats.String(); stat != want {
+		t.Errorf("stats = %q, want %q", stat, want)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/fs"
+	"time"
+)
+
+// quicktimeEpoch is the epoch QuickTime/MP4 "mvhd" timestamps are measured
+// from: 1904-01-01 00:00:00 UTC.
+var quicktimeEpoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// quicktimeExtractor reads the creation time out of a QuickTime/MP4 file's
+// "moov/mvhd" box.
+type quicktimeExtractor struct{}
+
+func (quicktimeExtractor) ExtractDate(fsys fs.FS, path string) (time.Time, bool, error) {
+	f, err := fsys.Open(path)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	defer f.Close()
+
+	rs, ok := f.(io.ReadSeeker)
+	if !ok {
+		return time.Time{}, false, fmt.Errorf("%s: file does not support seeking", path)
+	}
+
+	moov, err := findBoxBody(rs, "moov")
+	if err != nil {
+		return time.Time{}, false, nil
+	}
+	mvhd, err := findBoxBody(bytes.NewReader(moov), "mvhd")
+	if err != nil {
+		return time.Time{}, false, nil
+	}
+
+	secs, ok := mvhdCreationSeconds(mvhd)
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	return quicktimeEpoch.Add(time.Duration(secs) * time.Second), true, nil
+}
+
+// mvhdCreationSeconds extracts the creation_time field from an "mvhd" box
+// body, in seconds since quicktimeEpoch. The field is a uint32 in version 0
+// and a uint64 in version 1.
+func mvhdCreationSeconds(body []byte) (uint64, bool) {
+	if len(body) < 1 {
+		return 0, false
+	}
+	switch version := body[0]; version {
+	case 0:
+		if len(body) < 8 {
+			return 0, false
+		}
+		return uint64(binary.BigEndian.Uint32(body[4:8])), true
+	case 1:
+		if len(body) < 16 {
+			return 0, false
+		}
+		return binary.BigEndian.Uint64(body[4:12]), true
+	default:
+		return 0, false
+	}
+}
+
+// findBoxBody scans the ISO-BMFF boxes readable from r for a top-level box
+// with the given 4-character type and returns its body. It does not support
+// the 64-bit "size == 1" box size extension, which isn't needed to reach
+// moov/mvhd in practice.
+func findBoxBody(r io.ReadSeeker, boxType string) ([]byte, error) {
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, fmt.Errorf("box %q not found", boxType)
+		}
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		typ := string(header[4:8])
+		if size < 8 {
+			return nil, fmt.Errorf("invalid box size %d for %q", size, typ)
+		}
+		bodySize := size - 8
+		if typ == boxType {
+			body := make([]byte, bodySize)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return nil, err
+			}
+			return body, nil
+		}
+		if _, err := r.Seek(bodySize, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+	}
+}
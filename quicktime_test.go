@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// buildBox wraps body in an ISO-BMFF box header: a 4-byte big-endian size
+// followed by the 4-character type.
+func buildBox(boxType string, body []byte) []byte {
+	buf := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(buf[:4], uint32(8+len(body)))
+	copy(buf[4:8], boxType)
+	return append(buf, body...)
+}
+
+func TestFindBoxBodyTopLevel(t *testing.T) {
+	moovBody := []byte("moov-payload")
+	stream := append(buildBox("ftyp", []byte("isom")), buildBox("moov", moovBody)...)
+
+	got, err := findBoxBody(bytes.NewReader(stream), "moov")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(got, moovBody) {
+		t.Errorf("findBoxBody(moov) = %q, want %q", got, moovBody)
+	}
+}
+
+func TestFindBoxBodyNested(t *testing.T) {
+	mvhdBody := []byte("mvhd-payload")
+	moovBody := append(buildBox("trak", []byte("trak-payload")), buildBox("mvhd", mvhdBody)...)
+
+	got, err := findBoxBody(bytes.NewReader(moovBody), "mvhd")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(got, mvhdBody) {
+		t.Errorf("findBoxBody(mvhd) = %q, want %q", got, mvhdBody)
+	}
+}
+
+func TestFindBoxBodyNotFound(t *testing.T) {
+	stream := buildBox("ftyp", []byte("isom"))
+	if _, err := findBoxBody(bytes.NewReader(stream), "moov"); err == nil {
+		t.Error("expected an error when the box isn't present, got nil")
+	}
+}
+
+func TestMvhdCreationSecondsV0(t *testing.T) {
+	body := make([]byte, 8)
+	body[0] = 0 // version
+	binary.BigEndian.PutUint32(body[4:8], 12345)
+
+	secs, ok := mvhdCreationSeconds(body)
+	if !ok || secs != 12345 {
+		t.Errorf("mvhdCreationSeconds(v0) = %d, %v; want 12345, true", secs, ok)
+	}
+}
+
+func TestMvhdCreationSecondsV1(t *testing.T) {
+	body := make([]byte, 16)
+	body[0] = 1 // version
+	binary.BigEndian.PutUint64(body[4:12], 9876543210)
+
+	secs, ok := mvhdCreationSeconds(body)
+	if !ok || secs != 9876543210 {
+		t.Errorf("mvhdCreationSeconds(v1) = %d, %v; want 9876543210, true", secs, ok)
+	}
+}
+
+func TestMvhdCreationSecondsUnsupportedVersion(t *testing.T) {
+	body := make([]byte, 16)
+	body[0] = 2
+	if _, ok := mvhdCreationSeconds(body); ok {
+		t.Error("mvhdCreationSeconds accepted an unsupported version")
+	}
+}
+
+func TestMvhdCreationSecondsTooShort(t *testing.T) {
+	if _, ok := mvhdCreationSeconds([]byte{0, 0, 0}); ok {
+		t.Error("mvhdCreationSeconds accepted a truncated body")
+	}
+}
+
+func TestQuicktimeExtractorExtractDate(t *testing.T) {
+	wantSecs := uint32(3911990400) // an arbitrary time well after the 1904 epoch
+	mvhdBody := make([]byte, 8)
+	mvhdBody[0] = 0
+	binary.BigEndian.PutUint32(mvhdBody[4:8], wantSecs)
+
+	moovBody := buildBox("mvhd", mvhdBody)
+	stream := append(buildBox("ftyp", []byte("isom")), buildBox("moov", moovBody)...)
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "clip.mov"), stream, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok, err := (quicktimeExtractor{}).ExtractDate(os.DirFS(dir), "clip.mov")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !ok {
+		t.Fatal("ExtractDate reported ok=false for a well-formed file")
+	}
+	want := quicktimeEpoch.Add(time.Duration(wantSecs) * time.Second)
+	if !got.Equal(want) {
+		t.Errorf("ExtractDate = %v, want %v", got, want)
+	}
+}
+
+func TestQuicktimeExtractorExtractDateNoMoov(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "notavideo.mov"), buildBox("ftyp", []byte("isom")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, ok, err := (quicktimeExtractor{}).ExtractDate(os.DirFS(dir), "notavideo.mov")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ok {
+		t.Error("ExtractDate reported ok=true for a file with no moov/mvhd box")
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Stats tallies the outcome of every image the pipeline processes.
+type Stats struct {
+	mu        sync.Mutex
+	created   int
+	duplicate int
+	skipped   int
+	errors    int
+}
+
+func (s *Stats) recordCreated() {
+	s.mu.Lock()
+	s.created++
+	s.mu.Unlock()
+}
+
+func (s *Stats) recordDuplicate() {
+	s.mu.Lock()
+	s.duplicate++
+	s.mu.Unlock()
+}
+
+func (s *Stats) recordSkipped() {
+	s.mu.Lock()
+	s.skipped++
+	s.mu.Unlock()
+}
+
+func (s *Stats) recordError() {
+	s.mu.Lock()
+	s.errors++
+	s.mu.Unlock()
+}
+
+func (s *Stats) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fmt.Sprintf("created=%d duplicate=%d skipped=%d error=%d", s.created, s.duplicate, s.skipped, s.errors)
+}